@@ -0,0 +1,108 @@
+// Copyright 2015 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditRecord is one structured log line describing a mutating action
+// gitsync took, or would have taken had -dry-run not been set.
+type auditRecord struct {
+	Time     time.Time `json:"time"`
+	ChangeID string    `json:"change_id,omitempty"`
+	Project  string    `json:"project,omitempty"`
+	Action   string    `json:"action"`
+	PRNumber int       `json:"pr_number,omitempty"`
+	FromSHA  string    `json:"from_sha,omitempty"`
+	ToSHA    string    `json:"to_sha,omitempty"`
+	DryRun   bool      `json:"dry_run"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// auditLog writes auditRecords as JSON lines to an underlying writer and
+// keeps the most recent max of them in memory for the /audit endpoint.
+type auditLog struct {
+	w   io.Writer
+	max int
+
+	mu      sync.Mutex
+	records []auditRecord
+}
+
+func newAuditLog(w io.Writer, max int) *auditLog {
+	if max <= 0 {
+		max = 1
+	}
+	return &auditLog{w: w, max: max}
+}
+
+// openAuditLog opens the file at path for appending, or returns os.Stdout
+// if path is empty.
+func openAuditLog(path string) (io.Writer, error) {
+	if path == "" {
+		return os.Stdout, nil
+	}
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+}
+
+// log stamps rec with the current time, writes it as a JSON line, and
+// appends it to the in-memory ring buffer served at /audit.
+func (a *auditLog) log(rec auditRecord) {
+	rec.Time = time.Now()
+
+	a.mu.Lock()
+	a.records = append(a.records, rec)
+	if len(a.records) > a.max {
+		a.records = a.records[len(a.records)-a.max:]
+	}
+	a.mu.Unlock()
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("audit: marshaling record: %v", err)
+		return
+	}
+	if _, err := a.w.Write(append(b, '\n')); err != nil {
+		log.Printf("audit: writing record: %v", err)
+	}
+}
+
+// recent returns the most recently logged records, oldest first.
+func (a *auditLog) recent() []auditRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]auditRecord, len(a.records))
+	copy(out, a.records)
+	return out
+}
+
+// auditAction runs fn and records an audit log entry describing the
+// result. In -dry-run mode fn is not called at all; the action is logged
+// as if it had succeeded.
+func (s *Sync) auditAction(rec auditRecord, fn func() error) error {
+	rec.DryRun = s.DryRun
+	var err error
+	if !s.DryRun {
+		err = fn()
+		if err != nil {
+			rec.Error = err.Error()
+		}
+	}
+	s.audit.log(rec)
+	return err
+}
+
+// serveAudit serves the most recently logged audit records as JSON.
+func (s *Sync) serveAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.audit.recent())
+}
@@ -0,0 +1,58 @@
+// Copyright 2015 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// statusProviderConfig is the schema of the -ci-config file, e.g.:
+//
+//	{"providers": [
+//		{"type": "travis"},
+//		{"type": "github-actions"},
+//		{"type": "status", "context": "ci/jenkins"}
+//	]}
+type statusProviderConfig struct {
+	Providers []struct {
+		Type    string `json:"type"`
+		Context string `json:"context"`
+	} `json:"providers"`
+}
+
+// loadStatusProviders reads a status provider config file and returns the
+// corresponding StatusProviders. An empty path returns the default (Travis
+// CI only), preserving prior behavior.
+func loadStatusProviders(path string) ([]StatusProvider, error) {
+	if path == "" {
+		return []StatusProvider{TravisProvider()}, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg statusProviderConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", path, err)
+	}
+	var providers []StatusProvider
+	for _, p := range cfg.Providers {
+		switch p.Type {
+		case "travis":
+			providers = append(providers, TravisProvider())
+		case "github-actions":
+			providers = append(providers, GitHubActionsProvider{})
+		case "circleci":
+			providers = append(providers, CircleCIProvider(p.Context))
+		case "status":
+			providers = append(providers, GenericStatusProvider(p.Context))
+		default:
+			return nil, fmt.Errorf("%v: unknown status provider type %q", path, p.Type)
+		}
+	}
+	return providers, nil
+}
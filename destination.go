@@ -0,0 +1,37 @@
+// Copyright 2015 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "golang.org/x/build/gerrit"
+
+// Review is an open review (a GitHub pull request, a GitLab merge request,
+// or the equivalent) on some Destination, generalized across hosting
+// providers.
+type Review struct {
+	Number   int
+	State    string
+	HeadRef  string
+	HeadSHA  string
+	HeadRepo string // Repo the head branch lives in: "owner/repo" on GitHub, the bare project name on GitLab.
+	BaseRef  string
+}
+
+// Destination is a place a Gerrit change can be mirrored to: somewhere to
+// push the change's branch, and (optionally) a review system to open,
+// close, and read reviews from. A single Gerrit project may be mirrored to
+// several Destinations at once.
+type Destination interface {
+	// PushRef force-pushes sha to ref in project.
+	PushRef(project, ref, sha string) error
+	// DeleteRef deletes ref in project.
+	DeleteRef(project, ref string) error
+	// OpenReview opens a review for the Gerrit change ci.
+	OpenReview(ci *gerrit.ChangeInfo) error
+	// CloseReview closes the review rv.
+	CloseReview(rv *Review) error
+	// FetchReviews lists the reviews for project. The list may include
+	// closed reviews, which have State "closed".
+	FetchReviews(project string) ([]*Review, error)
+}
@@ -0,0 +1,110 @@
+// Copyright 2015 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// destinationsConfig maps each Gerrit project to the list of destinations
+// it should be mirrored to, as loaded from the -dest-config file.
+type destinationsConfig struct {
+	Projects []struct {
+		Project      string            `json:"project"`
+		Destinations []destinationSpec `json:"destinations"`
+	} `json:"projects"`
+}
+
+// destinationSpec configures a single mirror destination. Type selects the
+// implementation; the remaining fields are interpreted according to Type.
+// AuthToken values starting with "$" are expanded from the environment, so
+// secrets need not be written to the config file.
+type destinationSpec struct {
+	Type string `json:"type"` // "github" (default), "gitlab", or "local".
+
+	Owner     string `json:"owner"`      // github, gitlab: organization or user; local: owner subdirectory.
+	AuthToken string `json:"auth_token"` // github, gitlab: API token.
+	BaseURL   string `json:"base_url"`   // gitlab: instance base URL.
+
+	Dir    string `json:"dir"`    // local: root directory for bare mirrors.
+	Hoster string `json:"hoster"` // local: upstream hoster subdirectory, e.g. "github.com".
+	LFS    bool   `json:"lfs"`    // local: also fetch Git LFS objects.
+}
+
+// loadDestinationsConfig reads a -dest-config file. An empty path returns a
+// nil config, which callers interpret as "mirror to -github only".
+func loadDestinationsConfig(path string) (*destinationsConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg destinationsConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %v: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+// destinationsFor returns the Destinations that project should be mirrored
+// to, rooted at the local working directory root. With no destination
+// config, it falls back to the single default GitHub destination.
+func (s *Sync) destinationsFor(project, root string) ([]Destination, error) {
+	if s.destConfig == nil {
+		return []Destination{&GitHubDestination{
+			Owner:     s.GitHubOwner,
+			AuthToken: s.AuthToken,
+			Root:      root,
+		}}, nil
+	}
+	for _, p := range s.destConfig.Projects {
+		if p.Project != project {
+			continue
+		}
+		var dests []Destination
+		for _, spec := range p.Destinations {
+			d, err := newDestination(spec, root)
+			if err != nil {
+				return nil, err
+			}
+			dests = append(dests, d)
+		}
+		return dests, nil
+	}
+	return nil, nil
+}
+
+func newDestination(spec destinationSpec, root string) (Destination, error) {
+	switch spec.Type {
+	case "", "github":
+		return &GitHubDestination{
+			Owner:     spec.Owner,
+			AuthToken: os.ExpandEnv(spec.AuthToken),
+			Root:      root,
+		}, nil
+	case "gitlab":
+		return &GitLabDestination{
+			BaseURL:   spec.BaseURL,
+			Owner:     spec.Owner,
+			AuthToken: os.ExpandEnv(spec.AuthToken),
+			Root:      root,
+		}, nil
+	case "local":
+		return &LocalDestination{
+			Dir:    spec.Dir,
+			Hoster: spec.Hoster,
+			Owner:  spec.Owner,
+			LFS:    spec.LFS,
+			Root:   root,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown destination type %q", spec.Type)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2015 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/build/gerrit"
+)
+
+// syncExternalPullRequest mirrors an external pull request (one whose head
+// branch is not a Gerrit Change-Id) into Gerrit as a change, so that
+// contributions opened directly on GitHub still go through Gerrit review.
+//
+// The PR's head is rebased onto its base branch inside a scratch worktree,
+// its commit message is rewritten to carry a Change-Id derived from the PR
+// number, repository and base branch, and the result is pushed to
+// refs/for/<base>. Because the Change-Id is stable across runs, moving the
+// PR head simply adds a new patch set to the same change. Closing the PR
+// abandons the change.
+func (s *Sync) syncExternalPullRequest(root string, gd *GitHubDestination, rv *Review) error {
+	changeID := externalChangeID(rv)
+
+	if rv.State == "closed" {
+		return s.abandonGerritChange(changeID, fmt.Sprintf("Pull request #%d closed.", rv.Number))
+	}
+
+	// HeadRepo is "owner/repo": two different owners can fork a
+	// same-named Gerrit project, so the lock and working directory must
+	// both be keyed on the full owner/repo, not just repo, or concurrent
+	// pollers (the -workers pool) would clobber each other's checkouts.
+	unlock := s.externalLocks.lock(rv.HeadRepo)
+	defer unlock()
+
+	repo := strings.SplitN(rv.HeadRepo, "/", 2)[1]
+
+	// The bare mirror's "origin" points at the Gerrit project, so the PR's
+	// base branch can be fetched from there as usual; the fork itself is
+	// never a persisted remote, since its URL (and the token used to
+	// authenticate to it) is only known here, at fetch time.
+	bareRepo, err := openExternalBareRepo(root, s.GerritURL, repo, rv.HeadRepo)
+	if err != nil {
+		return err
+	}
+	baseHash, err := fetchChangeRef(bareRepo, "refs/heads/"+rv.BaseRef)
+	if err != nil {
+		return fmt.Errorf("fetching base ref %v: %v", rv.BaseRef, err)
+	}
+	forkURL := "https://" + gd.AuthToken + "@github.com/" + rv.HeadRepo
+	headHash, err := fetchRef(bareRepo, forkURL, rv.HeadSHA, "refs/heads/pr-head")
+	if err != nil {
+		return fmt.Errorf("fetching pull request #%d head %v from %v: %v", rv.Number, rv.HeadSHA, rv.HeadRepo, err)
+	}
+
+	bareDir := externalBareRepoDir(root, rv.HeadRepo)
+	wt := externalWorktreeDir(root, rv.HeadRepo, rv.Number)
+	if err := addWorktree(bareDir, wt, headHash); err != nil {
+		return err
+	}
+	defer removeWorktree(bareDir, wt)
+
+	if err := git(wt, "rebase", baseHash.String()); err != nil {
+		return fmt.Errorf("rebasing pull request #%d onto %v: %v", rv.Number, rv.BaseRef, err)
+	}
+	if err := addChangeID(wt, changeID); err != nil {
+		return err
+	}
+
+	dest := s.GerritURL + "/" + repo
+	refspec := fmt.Sprintf("HEAD:refs/for/%s", rv.BaseRef)
+	err = s.auditAction(auditRecord{
+		ChangeID: changeID, Project: repo, PRNumber: rv.Number, Action: "push_external_pr", ToSHA: rv.HeadSHA,
+	}, func() error {
+		return pushRef(wt, dest, refspec)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Pull request #%d posted to Gerrit as change %v.", rv.Number, changeID)
+	url := s.GerritURL + "/c/" + repo + "/+/" + changeID
+	return s.auditAction(auditRecord{
+		ChangeID: changeID, Project: repo, PRNumber: rv.Number, Action: "comment_external_pr",
+	}, func() error {
+		return gd.commentOnReview(rv, "Posted to Gerrit for review: "+url)
+	})
+}
+
+// externalChangeID derives a stable Gerrit Change-Id for an external pull
+// request from its number, repository and base branch, so that re-syncing
+// the same pull request updates the existing change instead of creating a
+// duplicate.
+func externalChangeID(rv *Review) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%d:%s:%s", rv.Number, rv.HeadRepo, rv.BaseRef)
+	return "I" + hex.EncodeToString(h.Sum(nil))
+}
+
+// addChangeID rewrites the HEAD commit message in dir so that it carries the
+// given Change-Id footer, replacing any existing one.
+func addChangeID(dir, changeID string) error {
+	out, err := gitOutput(dir, "log", "-1", "--pretty=%B")
+	if err != nil {
+		return err
+	}
+	footer := "Change-Id: " + changeID
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	replaced := false
+	for i, l := range lines {
+		if strings.HasPrefix(l, "Change-Id: ") {
+			lines[i] = footer
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, "", footer)
+	}
+	return git(dir, "commit", "--amend", "-m", strings.Join(lines, "\n"))
+}
+
+// abandonGerritChange abandons the Gerrit change with the given Change-Id,
+// if it exists and is still open. It is a no-op if the change does not
+// exist or has already been closed.
+func (s *Sync) abandonGerritChange(changeID, message string) error {
+	ctx := context.Background()
+	ci, err := s.gerrit.GetChange(ctx, changeID, gerrit.QueryChangesOpt{})
+	if err != nil {
+		if errors.Is(err, gerrit.ErrResourceNotExist) {
+			return nil
+		}
+		return err
+	}
+	if ci.Status != "NEW" {
+		return nil
+	}
+	return s.auditAction(auditRecord{ChangeID: changeID, Action: "abandon_change"}, func() error {
+		return s.gerrit.AbandonChange(ctx, changeID, message)
+	})
+}
+
+// commentOnReview posts msg as a comment on the pull request backing rv,
+// which lives in d.Owner's repository named like rv's head repository.
+func (d *GitHubDestination) commentOnReview(rv *Review, msg string) error {
+	payload := struct {
+		Body string `json:"body"`
+	}{msg}
+	repo := d.Owner + "/" + strings.SplitN(rv.HeadRepo, "/", 2)[1]
+	return d.github("repos/"+repo+"/issues/"+fmt.Sprint(rv.Number)+"/comments", payload, nil)
+}
+
+// gitOutput runs git with the given arguments in dir and returns its
+// standard output.
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %v: %v", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2015 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/build/gerrit"
+)
+
+// GitHubDestination mirrors Gerrit changes to pull requests in a GitHub
+// repository. It is the default Destination, matching gitsync's original
+// behavior.
+type GitHubDestination struct {
+	Owner     string // GitHub user or organization.
+	AuthToken string // GitHub authentication token (user:hex).
+	Root      string // Work directory holding each project's bare repo and change worktrees.
+}
+
+func (d *GitHubDestination) String() string { return "github:" + d.Owner }
+
+func (d *GitHubDestination) PushRef(project, ref, sha string) error {
+	dir := worktreeDir(d.Root, project, ref)
+	dest := "https://" + d.AuthToken + "@github.com/" + d.Owner + "/" + project
+	return pushRef(dir, dest, sha+":refs/heads/"+ref)
+}
+
+func (d *GitHubDestination) DeleteRef(project, ref string) error {
+	// go-git has no equivalent of `git push --delete`, so shell out.
+	dir := bareRepoDir(d.Root, project)
+	dest := "https://" + d.AuthToken + "@github.com/" + d.Owner + "/" + project
+	return git(dir, "push", "--delete", dest, ref)
+}
+
+func (d *GitHubDestination) OpenReview(ci *gerrit.ChangeInfo) error {
+	payload := struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+		Head  string `json:"head"`
+		Base  string `json:"base"`
+	}{
+		Title: ci.Subject,
+		Body:  "Automatically created pull request. **Do not review or merge this PR.**",
+		Head:  ci.ChangeID,
+		Base:  "master",
+	}
+	return d.github("repos/"+d.Owner+"/"+ci.Project+"/pulls", payload, nil)
+}
+
+func (d *GitHubDestination) CloseReview(rv *Review) error {
+	payload := struct {
+		State string `json:"state"`
+	}{"closed"}
+	return d.github("repos/"+rv.HeadRepo+"/pulls/"+fmt.Sprint(rv.Number), payload, nil)
+}
+
+// FetchReviews fetches both open and closed pull requests against project,
+// so that a pull request closed between polls is still seen and its change
+// can be abandoned in Gerrit; GitHub defaults to open-only otherwise.
+func (d *GitHubDestination) FetchReviews(project string) ([]*Review, error) {
+	var prs []*PullRequest
+	if err := d.github("repos/"+d.Owner+"/"+project+"/pulls?state=all", nil, &prs); err != nil {
+		return nil, err
+	}
+	reviews := make([]*Review, len(prs))
+	for i, pr := range prs {
+		reviews[i] = &Review{
+			Number:   pr.Number,
+			State:    pr.State,
+			HeadRef:  pr.Head.Ref,
+			HeadSHA:  pr.Head.SHA,
+			HeadRepo: pr.Head.Repo.Name,
+			BaseRef:  pr.Base.Ref,
+		}
+	}
+	return reviews, nil
+}
+
+// Repos lists the repositories owned by d.Owner. It is used for default
+// project discovery when no destination config file is supplied.
+func (d *GitHubDestination) Repos() ([]string, error) {
+	var result []struct {
+		Name string
+	}
+	if err := d.github("users/"+d.Owner+"/repos", nil, &result); err != nil {
+		return nil, err
+	}
+	var repos []string
+	for _, r := range result {
+		repos = append(repos, r.Name)
+	}
+	return repos, nil
+}
+
+func (d *GitHubDestination) github(path string, payload, result interface{}) error {
+	return githubRequest(d.AuthToken, path, payload, result)
+}
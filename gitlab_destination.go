@@ -0,0 +1,136 @@
+// Copyright 2015 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/build/gerrit"
+)
+
+// GitLabDestination mirrors Gerrit changes to merge requests on a GitLab
+// instance.
+type GitLabDestination struct {
+	BaseURL   string // GitLab instance, e.g. "https://gitlab.com".
+	Owner     string // GitLab namespace (user or group).
+	AuthToken string // GitLab personal access token.
+	Root      string // Work directory holding each project's bare repo and change worktrees.
+}
+
+func (d *GitLabDestination) String() string { return "gitlab:" + d.Owner }
+
+func (d *GitLabDestination) remoteURL(project string) string {
+	u, _ := url.Parse(d.BaseURL)
+	u.User = url.UserPassword("oauth2", d.AuthToken)
+	u.Path = "/" + d.Owner + "/" + project + ".git"
+	return u.String()
+}
+
+func (d *GitLabDestination) PushRef(project, ref, sha string) error {
+	dir := worktreeDir(d.Root, project, ref)
+	return pushRef(dir, d.remoteURL(project), sha+":refs/heads/"+ref)
+}
+
+func (d *GitLabDestination) DeleteRef(project, ref string) error {
+	// go-git has no equivalent of `git push --delete`, so shell out.
+	dir := bareRepoDir(d.Root, project)
+	return git(dir, "push", "--delete", d.remoteURL(project), ref)
+}
+
+func (d *GitLabDestination) OpenReview(ci *gerrit.ChangeInfo) error {
+	payload := struct {
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+	}{
+		SourceBranch: ci.ChangeID,
+		TargetBranch: "master",
+		Title:        ci.Subject,
+		Description:  "Automatically created merge request. **Do not review or merge this MR.**",
+	}
+	return d.api("POST", "projects/"+d.projectPath(ci.Project)+"/merge_requests", payload, nil)
+}
+
+func (d *GitLabDestination) CloseReview(rv *Review) error {
+	payload := struct {
+		StateEvent string `json:"state_event"`
+	}{"close"}
+	path := fmt.Sprintf("projects/%s/merge_requests/%d", d.projectPath(rv.HeadRepo), rv.Number)
+	return d.api("PUT", path, payload, nil)
+}
+
+func (d *GitLabDestination) FetchReviews(project string) ([]*Review, error) {
+	var mrs []struct {
+		IID          int    `json:"iid"`
+		State        string `json:"state"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		SHA          string `json:"sha"`
+	}
+	path := "projects/" + d.projectPath(project) + "/merge_requests?state=opened"
+	if err := d.api("GET", path, nil, &mrs); err != nil {
+		return nil, err
+	}
+	reviews := make([]*Review, len(mrs))
+	for i, mr := range mrs {
+		reviews[i] = &Review{
+			Number:   mr.IID,
+			State:    mr.State,
+			HeadRef:  mr.SourceBranch,
+			HeadSHA:  mr.SHA,
+			HeadRepo: project,
+			BaseRef:  mr.TargetBranch,
+		}
+	}
+	return reviews, nil
+}
+
+func (d *GitLabDestination) projectPath(project string) string {
+	return url.PathEscape(d.Owner + "/" + project)
+}
+
+func (d *GitLabDestination) api(method, path string, payload, result interface{}) error {
+	apiURL := d.BaseURL + "/api/v4/" + path
+
+	var body *bytes.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, apiURL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", d.AuthToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("gitlab: %v: %s", resp.Status, b)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(b, result)
+}
@@ -0,0 +1,34 @@
+// Copyright 2015 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// serveHealth reports the time of the last poll cycle that completed
+// without error and the
+// most recent sync outcome for each project.
+func (s *Sync) serveHealth(w http.ResponseWriter, r *http.Request) {
+	s.healthMu.Lock()
+	projects := make(map[string]*projectStatus, len(s.projectStatus))
+	for k, v := range s.projectStatus {
+		v := *v
+		projects[k] = &v
+	}
+	resp := struct {
+		LastPoll time.Time                 `json:"last_poll"`
+		Projects map[string]*projectStatus `json:"projects"`
+	}{
+		LastPoll: s.lastPollTime,
+		Projects: projects,
+	}
+	s.healthMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,74 @@
+// Copyright 2015 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/build/gerrit"
+)
+
+// LocalDestination maintains a bare mirror of each project on local disk,
+// in the <Dir>/<Hoster>/<Owner>/<repo>.git layout. It has no associated
+// review system, so OpenReview, CloseReview, and FetchReviews are no-ops:
+// branches are simply mirrored as-is.
+type LocalDestination struct {
+	Dir    string // Root directory for bare mirrors.
+	Hoster string // Subdirectory identifying the upstream hoster, e.g. "github.com".
+	Owner  string // Subdirectory identifying the upstream owner.
+	LFS    bool   // Also fetch Git LFS objects after each push.
+	Root   string // Work directory holding each project's bare repo and change worktrees (the sync source).
+}
+
+func (d *LocalDestination) String() string { return "local:" + d.Dir }
+
+func (d *LocalDestination) mirrorPath(project string) string {
+	return filepath.Join(d.Dir, d.Hoster, d.Owner, project+".git")
+}
+
+func (d *LocalDestination) ensureMirror(project string) (string, error) {
+	path := d.mirrorPath(project)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return "", err
+		}
+		if err := git("", "init", "--bare", path); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (d *LocalDestination) PushRef(project, ref, sha string) error {
+	mirror, err := d.ensureMirror(project)
+	if err != nil {
+		return err
+	}
+	dir := worktreeDir(d.Root, project, ref)
+	if err := pushRef(dir, mirror, sha+":refs/heads/"+ref); err != nil {
+		return err
+	}
+	if d.LFS {
+		git(mirror, "lfs", "fetch", "--all") // Best effort: LFS is optional.
+	}
+	return nil
+}
+
+func (d *LocalDestination) DeleteRef(project, ref string) error {
+	// go-git's branch deletion plumbing doesn't cover bare repos cleanly,
+	// so shell out, matching the other destinations' DeleteRef.
+	mirror, err := d.ensureMirror(project)
+	if err != nil {
+		return err
+	}
+	return git(mirror, "branch", "-D", ref)
+}
+
+func (d *LocalDestination) OpenReview(ci *gerrit.ChangeInfo) error          { return nil }
+func (d *LocalDestination) CloseReview(rv *Review) error                   { return nil }
+func (d *LocalDestination) FetchReviews(project string) ([]*Review, error) { return nil, nil }
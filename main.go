@@ -18,6 +18,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/build/gerrit"
@@ -30,13 +31,41 @@ func main() {
 		pollInterval = flag.Duration("poll", 10*time.Minute, "Poll `interval` (ignored when -cron set)")
 		workDir      = flag.String("dir", "", "Work `directory`, if empty $TMPDIR is used")
 		cronJob      = flag.Bool("cron", false, "Run once only; do not poll")
+		httpAddr     = flag.String("http", "", "If set, serve webhooks, archive tarballs, and a health check at this `address` (e.g. :8080)")
+		ciConfig     = flag.String("ci-config", "", "Path to a JSON `file` configuring CI status providers (default: Travis CI only)")
+		destConfig   = flag.String("dest-config", "", "Path to a JSON `file` configuring mirror destinations per project (default: mirror to -github only)")
+		archiveCache = flag.Int("archive-cache", 32, "Number of generated archive tarballs to keep cached on disk")
+		workers      = flag.Int("workers", 4, "Number of projects to poll concurrently")
+		dryRun       = flag.Bool("dry-run", false, "Log mutating actions (git push, GitHub POST, Gerrit SetReview) instead of performing them")
+		auditLogPath = flag.String("audit-log", "", "Path to a `file` to append the JSON-lines audit log to (default: stdout)")
+		auditHistory = flag.Int("audit-history", 500, "Number of recent audit records to keep for the /audit endpoint")
 	)
 	flag.Parse()
+	providers, err := loadStatusProviders(*ciConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dests, err := loadDestinationsConfig(*destConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auditWriter, err := openAuditLog(*auditLogPath)
+	if err != nil {
+		log.Fatal(err)
+	}
 	s := Sync{
-		GerritURL:    *gerritURL,
-		GitHubOwner:  *githubOwner,
-		PollInterval: *pollInterval,
-		AuthToken:    os.Getenv("GITSYNC_AUTH_TOKEN"),
+		GerritURL:       *gerritURL,
+		GitHubOwner:     *githubOwner,
+		PollInterval:    *pollInterval,
+		AuthToken:       os.Getenv("GITSYNC_AUTH_TOKEN"),
+		HTTPAddr:         *httpAddr,
+		WebhookSecret:    os.Getenv("GITSYNC_WEBHOOK_SECRET"),
+		StatusProviders:  providers,
+		ArchiveCacheSize: *archiveCache,
+		Workers:          *workers,
+		DryRun:           *dryRun,
+		destConfig:       dests,
+		audit:            newAuditLog(auditWriter, *auditHistory),
 	}
 	if !strings.Contains(s.AuthToken, ":") {
 		fmt.Fprintln(os.Stderr, `You must set GITSYNC_AUTH_TOKEN to "username:personal-access-token".`)
@@ -55,18 +84,54 @@ type Sync struct {
 
 	PollInterval time.Duration
 
-	gerrit *gerrit.Client
+	HTTPAddr         string // Address to serve webhooks/archives/health on, if non-empty.
+	WebhookSecret    string // Shared secret for verifying webhook deliveries.
+	StatusProviders  []StatusProvider
+	ArchiveCacheSize int  // Number of generated archive tarballs to keep cached on disk.
+	Workers          int  // Number of projects to poll concurrently.
+	DryRun           bool // Log mutating actions instead of performing them.
+
+	gerrit     *gerrit.Client
+	kick       chan struct{}
+	destConfig *destinationsConfig
+	archives   *archiveCache
+	audit      *auditLog
+
+	projectLocks  namedLocks
+	externalLocks namedLocks
+
+	changeCacheMu sync.Mutex
+	changeCache   map[string]syncedChange
+
+	healthMu      sync.Mutex
+	lastPollTime  time.Time
+	projectStatus map[string]*projectStatus
+}
+
+// syncedChange records the Gerrit revision a change's local worktree was
+// last synced to, so that unchanged changes can skip the fetch entirely.
+type syncedChange struct {
+	revision string
+	worktree string
+}
+
+// projectStatus records the most recent sync outcome for a single Gerrit
+// project, surfaced over the /health endpoint.
+type projectStatus struct {
+	LastSync time.Time `json:"last_sync"`
+	Error    string    `json:"error,omitempty"`
 }
 
 type Change struct {
 	*gerrit.ChangeInfo
-	*PullRequest
+	*Review
 }
 
 // GitHub API
 
 type PullRequest struct {
 	Number int
+	State  string
 	Head   GitHubRevision
 	Base   GitHubRevision
 }
@@ -89,6 +154,8 @@ type GitHubStatus struct {
 func (s *Sync) run(root string, cron bool) error {
 	auth := gerrit.GitCookiesAuth()
 	s.gerrit = gerrit.NewClient(s.GerritURL, auth)
+	s.kick = make(chan struct{}, 1)
+	s.projectStatus = map[string]*projectStatus{}
 
 	if root == "" {
 		var err error
@@ -98,170 +165,367 @@ func (s *Sync) run(root string, cron bool) error {
 		}
 		defer os.RemoveAll(root)
 	}
+	s.archives = newArchiveCache(filepath.Join(root, ".archives"), s.ArchiveCacheSize)
 
 	if cron {
 		return s.poll(root)
 	}
 
-	for range time.Tick(s.PollInterval) {
+	if s.HTTPAddr != "" {
+		go s.serveHTTP(root)
+	}
+
+	t := time.NewTicker(s.PollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-s.kick:
+		}
 		if err := s.poll(root); err != nil {
 			// TODO(adg): retry?
 			return err
 		}
 	}
-	panic("unreachable")
 }
 
-func (s *Sync) poll(root string) error {
-	changes := map[string]*Change{}
+// triggerPoll wakes the poll loop immediately instead of waiting for the
+// next tick. It is a no-op if a poll has already been requested and not
+// yet serviced.
+func (s *Sync) triggerPoll() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
 
+func (s *Sync) poll(root string) error {
 	cis, err := s.gerritChanges()
 	if err != nil {
 		return err
 	}
+	byProject := map[string][]*gerrit.ChangeInfo{}
 	for _, ci := range cis {
-		changes[ci.ChangeID] = &Change{
-			ChangeInfo: ci,
+		byProject[ci.Project] = append(byProject[ci.Project], ci)
+	}
+
+	projects, err := s.projects(cis)
+	if err != nil {
+		return err
+	}
+
+	// Sync each project in its own goroutine, bounded by s.Workers. A
+	// per-project mutex still serializes fetches against the same bare
+	// repo; different projects run concurrently.
+	sem := make(chan struct{}, s.workers())
+	var wg sync.WaitGroup
+	errs := make(chan error, len(projects))
+	for _, project := range projects {
+		project := project
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			unlock := s.lockProject(project)
+			defer unlock()
+			err := s.pollProject(root, project, byProject[project])
+			s.recordProjectStatus(project, err)
+			if err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		return err
+	}
+
+	s.recordPollSuccess()
+	return nil
+}
+
+// workers returns the configured worker pool size, defaulting to 1 (serial
+// polling) if unset.
+func (s *Sync) workers() int {
+	if s.Workers <= 0 {
+		return 1
+	}
+	return s.Workers
+}
+
+// namedLocks hands out a distinct *sync.Mutex per key, created on first
+// use, so unrelated keys can be locked concurrently.
+type namedLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock acquires the mutex for key and returns a function that releases it.
+func (n *namedLocks) lock(key string) func() {
+	n.mu.Lock()
+	l, ok := n.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		if n.locks == nil {
+			n.locks = map[string]*sync.Mutex{}
 		}
+		n.locks[key] = l
 	}
+	n.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
 
-	repos, err := s.githubRepos()
+// lockProject serializes git operations against project's bare repo,
+// returning a function that releases the lock.
+func (s *Sync) lockProject(project string) func() {
+	return s.projectLocks.lock(project)
+}
+
+// pollProject syncs project against every destination it is configured to
+// mirror to.
+func (s *Sync) pollProject(root, project string, cis []*gerrit.ChangeInfo) error {
+	dests, err := s.destinationsFor(project, root)
 	if err != nil {
 		return err
 	}
-	for _, repo := range repos {
-		prs, err := s.pullRequests(repo)
-		if err != nil {
+	for _, dest := range dests {
+		if err := s.syncDestination(root, project, cis, dest); err != nil {
 			return err
 		}
-		for _, pr := range prs {
-			if !isGerritChange(pr.Head.Ref) {
-				continue
-			}
-			c, ok := changes[pr.Head.Ref]
-			if !ok {
-				c = &Change{}
-				changes[pr.Head.Ref] = c
+	}
+	return nil
+}
+
+// recordProjectStatus records the outcome of the most recent sync attempt
+// for project, for the /health endpoint.
+func (s *Sync) recordProjectStatus(project string, err error) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	st := &projectStatus{LastSync: time.Now()}
+	if err != nil {
+		st.Error = err.Error()
+	}
+	s.projectStatus[project] = st
+}
+
+// recordPollSuccess records that a full poll cycle completed without error.
+func (s *Sync) recordPollSuccess() {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.lastPollTime = time.Now()
+}
+
+// syncDestination reconciles the Gerrit changes open against project with
+// the reviews currently open on dest, creating, updating, or closing
+// reviews as needed.
+func (s *Sync) syncDestination(root, project string, cis []*gerrit.ChangeInfo, dest Destination) error {
+	changes := map[string]*Change{}
+	for _, ci := range cis {
+		changes[ci.ChangeID] = &Change{
+			ChangeInfo: ci,
+		}
+	}
+
+	reviews, err := dest.FetchReviews(project)
+	if err != nil {
+		return err
+	}
+	for _, rv := range reviews {
+		if !isGerritChange(rv.HeadRef) {
+			// Not a Gerrit-originated branch: this is an external
+			// contribution. Mirror it into Gerrit as its own change.
+			if gd, ok := dest.(*GitHubDestination); ok {
+				if err := s.syncExternalPullRequest(root, gd, rv); err != nil {
+					return err
+				}
 			}
-			c.PullRequest = pr
+			continue
+		}
+		if rv.State == "closed" {
+			// Already closed; nothing left to sync or close.
+			continue
 		}
+		c, ok := changes[rv.HeadRef]
+		if !ok {
+			c = &Change{}
+			changes[rv.HeadRef] = c
+		}
+		c.Review = rv
 	}
 
 	for _, c := range changes {
 		switch {
-		case c.PullRequest == nil && c.ChangeInfo != nil:
-			// Sync branch and create pull request.
+		case c.Review == nil && c.ChangeInfo != nil:
+			// Sync branch and open a review.
 			ci := c.ChangeInfo
-			log.Printf("Gerrit change %v needs corresponding pull request. Creating one.", ci.ChangeID)
-			dir := filepath.Join(root, ci.Project)
-			if err := s.syncBranch(dir, ci); err != nil {
+			log.Printf("Gerrit change %v needs a review on %v. Creating one.", ci.ChangeID, dest)
+			if _, err := s.ensureBranchSynced(root, ci); err != nil {
+				return err
+			}
+			err := s.auditAction(auditRecord{
+				ChangeID: ci.ChangeID, Project: project, Action: "push_ref", ToSHA: ci.CurrentRevision,
+			}, func() error {
+				return dest.PushRef(project, ci.ChangeID, ci.CurrentRevision)
+			})
+			if err != nil {
 				return err
 			}
-			if err := s.createPullRequest(ci); err != nil {
+			err = s.auditAction(auditRecord{
+				ChangeID: ci.ChangeID, Project: project, Action: "open_review",
+			}, func() error {
+				return dest.OpenReview(ci)
+			})
+			if err != nil {
 				return err
 			}
-		case c.PullRequest != nil && c.ChangeInfo != nil:
+		case c.Review != nil && c.ChangeInfo != nil:
 			ci := c.ChangeInfo
-			if c.PullRequest.Head.SHA == c.ChangeInfo.CurrentRevision {
+			if c.Review.HeadSHA == ci.CurrentRevision {
 				// Already in sync; nothing to do.
-				log.Printf("Gerrit change %v already synced with pull request.", ci.ChangeID)
-				if err := s.syncComments(c); err != nil {
-					return err
+				log.Printf("Gerrit change %v already synced with %v.", ci.ChangeID, dest)
+				if _, ok := dest.(*GitHubDestination); ok {
+					if err := s.syncComments(ci, c.Review); err != nil {
+						return err
+					}
 				}
 				break
 			}
 			// Sync branch.
-			log.Printf("Gerrit change %v needs sync with pull request. Syncing.", ci.ChangeID)
-			dir := filepath.Join(root, ci.Project)
-			if err := s.syncBranch(dir, ci); err != nil {
+			log.Printf("Gerrit change %v needs sync with %v. Syncing.", ci.ChangeID, dest)
+			if _, err := s.ensureBranchSynced(root, ci); err != nil {
 				return err
 			}
-		case c.PullRequest != nil && c.ChangeInfo == nil:
-			// Close pull request and delete branch.
-			pr := c.PullRequest
-			log.Printf("Pull request %v has no corresponding Gerrit change. Closing.", pr.Number)
-			if err := s.closePullRequest(pr); err != nil {
+			err := s.auditAction(auditRecord{
+				ChangeID: ci.ChangeID, Project: project, Action: "push_ref",
+				FromSHA: c.Review.HeadSHA, ToSHA: ci.CurrentRevision,
+			}, func() error {
+				return dest.PushRef(project, ci.ChangeID, ci.CurrentRevision)
+			})
+			if err != nil {
 				return err
 			}
-			repo := strings.SplitN(pr.Head.Repo.Name, "/", 2)[1]
-			dir := filepath.Join(root, repo)
-			if err := s.deleteBranch(dir, repo, pr.Head.Ref); err != nil {
+		case c.Review != nil && c.ChangeInfo == nil:
+			// Close review and delete branch.
+			rv := c.Review
+			log.Printf("Review %v on %v has no corresponding Gerrit change. Closing.", rv.Number, dest)
+			err := s.auditAction(auditRecord{
+				Project: project, PRNumber: rv.Number, Action: "close_review",
+			}, func() error {
+				return dest.CloseReview(rv)
+			})
+			if err != nil {
 				return err
 			}
+			err = s.auditAction(auditRecord{
+				Project: project, PRNumber: rv.Number, Action: "delete_ref",
+			}, func() error {
+				return dest.DeleteRef(project, rv.HeadRef)
+			})
+			if err != nil {
+				return err
+			}
+			s.forgetChange(root, project, rv.HeadRef)
 		}
 	}
 
 	return nil
 }
 
+// projects returns the set of Gerrit projects to poll: those with open
+// changes, those named in the destination config, and (if no destination
+// config is set) those owned by the default GitHub destination.
+func (s *Sync) projects(cis []*gerrit.ChangeInfo) ([]string, error) {
+	set := map[string]bool{}
+	for _, ci := range cis {
+		set[ci.Project] = true
+	}
+	if s.destConfig != nil {
+		for _, p := range s.destConfig.Projects {
+			set[p.Project] = true
+		}
+	} else {
+		repos, err := (&GitHubDestination{Owner: s.GitHubOwner, AuthToken: s.AuthToken}).Repos()
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range repos {
+			set[r] = true
+		}
+	}
+	var projects []string
+	for p := range set {
+		projects = append(projects, p)
+	}
+	return projects, nil
+}
+
 func (s *Sync) gerritChanges() ([]*gerrit.ChangeInfo, error) {
 	ctx := context.Background()
 	opt := gerrit.QueryChangesOpt{Fields: []string{"CURRENT_REVISION", "MESSAGES"}}
 	return s.gerrit.QueryChanges(ctx, "is:open", opt)
 }
 
-func (s *Sync) syncBranch(dir string, c *gerrit.ChangeInfo) error {
-	if err := s.clone(dir, c.Project); err != nil {
-		return err
-	}
-	// Switch to the branch for this change.
-	if err := git(dir, "checkout", c.ChangeID); err != nil {
-		// Branch doesn't exist for this change; create one.
-		err2 := git(dir, "checkout", "-b", c.ChangeID)
-		if err2 != nil {
-			return err
-		}
-	}
-	// Reset the branch to the current change head.
-	src := s.GerritURL + "/" + c.Project
-	ref := c.Revisions[c.CurrentRevision].Ref
-	if err := git(dir, "fetch", src, ref); err != nil {
-		return err
-	}
-	if err := git(dir, "reset", "--hard", "FETCH_HEAD"); err != nil {
-		return err
+// ensureBranchSynced makes sure a worktree exists under root holding c's
+// current revision, fetching it only if the in-memory change cache does
+// not already know about this exact revision. It returns the worktree's
+// path.
+func (s *Sync) ensureBranchSynced(root string, c *gerrit.ChangeInfo) (string, error) {
+	s.changeCacheMu.Lock()
+	cached, ok := s.changeCache[c.ChangeID]
+	s.changeCacheMu.Unlock()
+	if ok && cached.revision == c.CurrentRevision {
+		return cached.worktree, nil
 	}
-	// Push the branch to GitHub.
-	dest := "https://" + s.AuthToken + "@github.com/" + s.GitHubOwner + "/" + c.Project
-	return git(dir, "push", "-f", dest, c.ChangeID)
-}
 
-func (s *Sync) deleteBranch(dir, repo, id string) error {
-	if err := s.clone(dir, repo); err != nil {
-		return err
+	wt, err := s.syncBranch(root, c)
+	if err != nil {
+		return "", err
 	}
-	// Delete the remote branch.
-	dest := "https://" + s.AuthToken + "@github.com/" + s.GitHubOwner + "/" + repo
-	if err := git(dir, "push", "--delete", dest, id); err != nil {
-		return err
+
+	s.changeCacheMu.Lock()
+	if s.changeCache == nil {
+		s.changeCache = map[string]syncedChange{}
 	}
-	// Delete the local branch.
-	git(dir, "branch", "-D", id) // Ignore errors.
-	return nil
+	s.changeCache[c.ChangeID] = syncedChange{revision: c.CurrentRevision, worktree: wt}
+	s.changeCacheMu.Unlock()
+	return wt, nil
 }
 
-func (s *Sync) clone(dir, project string) error {
-	if fi, err := os.Stat(dir); err != nil && !os.IsNotExist(err) {
-		return err
-	} else if err == nil {
-		if !fi.IsDir() {
-			return fmt.Errorf("clone destination is not a directory: %v", dir)
-		}
-		// We're already cloned here; so just do a pull to make sure we're up to date.
-		if err := git(dir, "checkout", "master"); err != nil {
-			return nil
-		}
-		return git(dir, "pull")
+// syncBranch fetches c's current revision into project's persistent bare
+// mirror and checks it out into a fresh ephemeral worktree, returning the
+// worktree's path. The bare mirror is created on first use and reused
+// across polls, so only the one change that moved needs fetching.
+func (s *Sync) syncBranch(root string, c *gerrit.ChangeInfo) (string, error) {
+	repo, err := openBareRepo(root, s.GerritURL, c.Project)
+	if err != nil {
+		return "", err
 	}
-	if err := os.MkdirAll(dir, 0777); err != nil {
-		return err
+	ref := c.Revisions[c.CurrentRevision].Ref
+	hash, err := fetchChangeRef(repo, ref)
+	if err != nil {
+		return "", err
 	}
-	url := s.GerritURL + "/" + project
-	if err := git(dir, "clone", url, dir); err != nil {
-		os.RemoveAll(dir)
-		return err
+	wt := worktreeDir(root, c.Project, c.ChangeID)
+	if err := addWorktree(bareRepoDir(root, c.Project), wt, hash); err != nil {
+		return "", err
 	}
-	return git(dir, "checkout", "master")
+	return wt, nil
+}
+
+// forgetChange evicts changeID's worktree from the change cache and
+// removes it from disk, once its review has been closed.
+func (s *Sync) forgetChange(root, project, changeID string) {
+	s.changeCacheMu.Lock()
+	cached, ok := s.changeCache[changeID]
+	delete(s.changeCache, changeID)
+	s.changeCacheMu.Unlock()
+	if !ok {
+		return
+	}
+	removeWorktree(bareRepoDir(root, project), cached.worktree)
 }
 
 func git(dir string, args ...string) error {
@@ -274,72 +538,53 @@ func git(dir string, args ...string) error {
 	return nil
 }
 
-func (s *Sync) pullRequests(repo string) (prs []*PullRequest, err error) {
-	return prs, s.github("repos/"+s.GitHubOwner+"/"+repo+"/pulls", nil, &prs)
-}
-
-func (s *Sync) createPullRequest(ci *gerrit.ChangeInfo) error {
-	payload := struct {
-		Title string `json:"title"`
-		Body  string `json:"body"`
-		Head  string `json:"head"`
-		Base  string `json:"base"`
-	}{
-		Title: ci.Subject,
-		Body:  "Automatically created pull request. **Do not review or merge this PR.**",
-		Head:  ci.ChangeID,
-		Base:  "master",
-	}
-	return s.github("repos/"+s.GitHubOwner+"/"+ci.Project+"/pulls", payload, nil)
-}
-
-func (s *Sync) closePullRequest(pr *PullRequest) error {
-	payload := struct {
-		State string `json:"state"`
-	}{"closed"}
-	return s.github("repos/"+pr.Head.Repo.Name+"/pulls/"+fmt.Sprint(pr.Number), payload, nil)
-}
-
-func (s *Sync) syncComments(c *Change) error {
-	pr := c.PullRequest
-	ci := c.ChangeInfo
-
-	// Fetch Pull Request statuses.
-	var statuses []*GitHubStatus
-	err := s.github("repos/"+pr.Head.Repo.Name+"/commits/"+pr.Head.SHA+"/statuses", nil, &statuses)
-	if err != nil {
-		return err
-	}
+// syncComments posts Gerrit review comments for CI results reported on the
+// GitHub pull request backing rv. CI status is always read with the
+// primary GITSYNC_AUTH_TOKEN credentials, regardless of which GitHub
+// destination rv came from, since a single upstream CI configuration
+// governs all of them.
+func (s *Sync) syncComments(ci *gerrit.ChangeInfo, rv *Review) error {
+	pr := &PullRequest{Number: rv.Number}
+	pr.Head.Ref = rv.HeadRef
+	pr.Head.SHA = rv.HeadSHA
+	pr.Head.Repo.Name = rv.HeadRepo
 
 	ctx := context.Background()
-	for _, stat := range statuses {
-		if stat.Context != "continuous-integration/travis-ci/pr" {
-			continue
-		}
-		if stat.State != "success" && stat.State != "failure" {
-			continue
+	for _, provider := range s.StatusProviders {
+		results, err := provider.Fetch(s, pr)
+		if err != nil {
+			return err
 		}
-		msg := fmt.Sprintf("%v: %v", stat.Description, stat.Target)
-
-		// Check whether an equivalent Gerrit comment exists.
-		found := false
-		for _, m := range ci.Messages {
-			if strings.Contains(m.Message, msg) {
-				found = true
-				break
+		for _, r := range results {
+			label, value, ok := provider.Vote(r)
+			if !ok {
+				continue
+			}
+			msg := provider.Comment(r)
+
+			// Check whether an equivalent Gerrit comment exists.
+			found := false
+			for _, m := range ci.Messages {
+				if strings.Contains(m.Message, msg) {
+					found = true
+					break
+				}
+			}
+			if found {
+				continue
 			}
-		}
-		if !found {
 			// If no such comment exists, post it.
 			var labels map[string]int
-			if stat.State == "failure" {
-				labels = map[string]int{
-					"Code-Review": -1,
-				}
+			if value != 0 {
+				labels = map[string]int{label: value}
 			}
-			err = s.gerrit.SetReview(ctx, ci.ChangeID, ci.CurrentRevision, gerrit.ReviewInput{
-				Message: msg,
-				Labels:  labels,
+			err = s.auditAction(auditRecord{
+				ChangeID: ci.ChangeID, Project: ci.Project, PRNumber: rv.Number, Action: "sync_comment",
+			}, func() error {
+				return s.gerrit.SetReview(ctx, ci.ChangeID, ci.CurrentRevision, gerrit.ReviewInput{
+					Message: msg,
+					Labels:  labels,
+				})
 			})
 			if err != nil {
 				return err
@@ -350,23 +595,19 @@ func (s *Sync) syncComments(c *Change) error {
 	return nil
 }
 
-func (s *Sync) githubRepos() ([]string, error) {
-	var result []struct {
-		Name string
-	}
-	err := s.github("users/"+s.GitHubOwner+"/repos", nil, &result)
-	if err != nil {
-		return nil, err
-	}
-	var repos []string
-	for _, r := range result {
-		repos = append(repos, r.Name)
-	}
-	return repos, nil
+// github issues an authenticated request against the GitHub API using s's
+// own credentials (the primary GITSYNC_AUTH_TOKEN). Destinations
+// that hold their own GitHub credentials use githubRequest directly.
+func (s *Sync) github(path string, payload, result interface{}) error {
+	return githubRequest(s.AuthToken, path, payload, result)
 }
 
-func (s *Sync) github(path string, payload, result interface{}) error {
-	url := "https://" + s.AuthToken + "@api.github.com/" + path
+// githubRequest issues an authenticated request against the GitHub API. If
+// payload is non-nil it is POSTed as the request body; otherwise a GET is
+// issued. If result is non-nil the JSON response body is unmarshaled into
+// it.
+func githubRequest(authToken, path string, payload, result interface{}) error {
+	url := "https://" + authToken + "@api.github.com/" + path
 
 	var r *http.Response
 	var err error
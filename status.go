@@ -0,0 +1,139 @@
+// Copyright 2015 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "fmt"
+
+// CheckResult is a single CI check result for a commit, normalized across
+// the various CI systems a StatusProvider may talk to.
+type CheckResult struct {
+	Context     string
+	State       string // e.g. "success", "failure", "pending".
+	Description string
+	URL         string
+}
+
+// StatusProvider knows how to fetch CI results for a pull request from one
+// CI system, and how to translate those results into a Gerrit review.
+type StatusProvider interface {
+	// Fetch retrieves the current check results for pr's head commit.
+	Fetch(s *Sync, pr *PullRequest) ([]CheckResult, error)
+	// Vote reports the Gerrit label and value to apply for r, or ok=false
+	// if r should be ignored (for example because it is still pending).
+	Vote(r CheckResult) (label string, value int, ok bool)
+	// Comment formats the Gerrit comment message to post for r.
+	Comment(r CheckResult) string
+}
+
+// statusProvider implements StatusProvider for any CI system that reports
+// through GitHub's commit statuses API, distinguished only by context:
+// Travis CI, CircleCI, and generic statuses all fit this shape.
+type statusProvider struct {
+	context string
+}
+
+// TravisProvider reports status from Travis CI's pull request builds.
+func TravisProvider() StatusProvider {
+	return statusProvider{context: "continuous-integration/travis-ci/pr"}
+}
+
+// CircleCIProvider reports status from CircleCI. If context is empty, the
+// default CircleCI context ("ci/circleci") is used.
+func CircleCIProvider(context string) StatusProvider {
+	if context == "" {
+		context = "ci/circleci"
+	}
+	return statusProvider{context: context}
+}
+
+// GenericStatusProvider reports status from any CI system that posts to
+// the commit statuses API under the given context.
+func GenericStatusProvider(context string) StatusProvider {
+	return statusProvider{context: context}
+}
+
+func (p statusProvider) Fetch(s *Sync, pr *PullRequest) ([]CheckResult, error) {
+	var statuses []*GitHubStatus
+	err := s.github("repos/"+pr.Head.Repo.Name+"/commits/"+pr.Head.SHA+"/statuses", nil, &statuses)
+	if err != nil {
+		return nil, err
+	}
+	var results []CheckResult
+	for _, st := range statuses {
+		if st.Context != p.context {
+			continue
+		}
+		results = append(results, CheckResult{
+			Context:     st.Context,
+			State:       st.State,
+			Description: st.Description,
+			URL:         st.Target,
+		})
+	}
+	return results, nil
+}
+
+func (p statusProvider) Vote(r CheckResult) (string, int, bool) {
+	switch r.State {
+	case "success":
+		return "Code-Review", 0, true
+	case "failure", "error":
+		return "Code-Review", -1, true
+	default:
+		return "", 0, false
+	}
+}
+
+func (p statusProvider) Comment(r CheckResult) string {
+	return fmt.Sprintf("%v: %v", r.Description, r.URL)
+}
+
+// GitHubActionsProvider reports status from GitHub Actions check runs,
+// which are reported through a different API than commit statuses.
+type GitHubActionsProvider struct{}
+
+func (GitHubActionsProvider) Fetch(s *Sync, pr *PullRequest) ([]CheckResult, error) {
+	var resp struct {
+		CheckRuns []struct {
+			Name       string `json:"name"`
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+			DetailsURL string `json:"details_url"`
+		} `json:"check_runs"`
+	}
+	err := s.github("repos/"+pr.Head.Repo.Name+"/commits/"+pr.Head.SHA+"/check-runs", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	var results []CheckResult
+	for _, cr := range resp.CheckRuns {
+		state := cr.Conclusion
+		if cr.Status != "completed" {
+			state = "pending"
+		}
+		results = append(results, CheckResult{
+			Context:     "github-actions/" + cr.Name,
+			State:       state,
+			Description: cr.Name,
+			URL:         cr.DetailsURL,
+		})
+	}
+	return results, nil
+}
+
+func (GitHubActionsProvider) Vote(r CheckResult) (string, int, bool) {
+	switch r.State {
+	case "success":
+		return "Code-Review", 0, true
+	case "failure", "timed_out", "cancelled":
+		return "Code-Review", -1, true
+	default:
+		return "", 0, false
+	}
+}
+
+func (GitHubActionsProvider) Comment(r CheckResult) string {
+	return fmt.Sprintf("%v: %v", r.Description, r.URL)
+}
@@ -0,0 +1,63 @@
+// Copyright 2015 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// serveHTTP starts the HTTP server on s.HTTPAddr: a GitHub webhook
+// receiver at /webhook, archive tarballs of synced projects at /project/,
+// a health check at /health, and the recent audit log at /audit.
+func (s *Sync) serveHTTP(root string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", s.handleWebhook)
+	mux.HandleFunc("/project/", func(w http.ResponseWriter, r *http.Request) {
+		s.serveArchive(w, r, root)
+	})
+	mux.HandleFunc("/health", s.serveHealth)
+	mux.HandleFunc("/audit", s.serveAudit)
+	log.Printf("Serving HTTP on %v", s.HTTPAddr)
+	log.Fatal(http.ListenAndServe(s.HTTPAddr, mux))
+}
+
+// handleWebhook validates a GitHub webhook delivery and, if it reports a
+// pull_request, status, or check_run event, kicks the poll loop so the
+// resulting Gerrit change appears without waiting for the next tick.
+func (s *Sync) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validSignature(s.WebhookSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	switch r.Header.Get("X-GitHub-Event") {
+	case "pull_request", "status", "check_run":
+		s.triggerPoll()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validSignature reports whether sig is a valid HMAC-SHA256 signature (as
+// sent in the X-Hub-Signature-256 header) of body under secret.
+func validSignature(secret string, body []byte, sig string) bool {
+	const prefix = "sha256="
+	if secret == "" || !strings.HasPrefix(sig, prefix) {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	want := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(want), []byte(strings.TrimPrefix(sig, prefix)))
+}